@@ -2,10 +2,13 @@ package gologloki
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/memUsins/golog"
+	"math/rand"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -20,10 +23,36 @@ type lokiAdapter struct {
 	quit  chan struct{}
 	wg    sync.WaitGroup
 
+	closeOnce sync.Once
+	closeMu   sync.Mutex
+	closed    bool
+	closeErr  error
+
+	wal *walStore
+
+	// shutdownCtx is the ctx passed to Close, set before a.quit is closed
+	// so batchProcessor's quit case can bound the final flush by it instead
+	// of flushing with an unbounded context.Background().
+	shutdownCtx context.Context
+
+	// streamLabelSet, metadataLabelSet and messageFieldSet are
+	// cfg.StreamLabels, cfg.MetadataLabels and cfg.MessageFields as sets,
+	// precomputed once so classifying a field on every Log call is an
+	// O(1) lookup instead of a slice scan.
+	streamLabelSet   map[string]struct{}
+	metadataLabelSet map[string]struct{}
+	messageFieldSet  map[string]struct{}
+
 	bufferMutex sync.Mutex
 	buffer      []lokiLogEntry
 
 	lastFlush time.Time
+
+	// warnedFieldsMu guards warnedFields, the set of field names already
+	// reported by warnUnknownFieldOnce, so a field outside MessageFields'
+	// declared schema is logged once total instead of once per log line.
+	warnedFieldsMu sync.Mutex
+	warnedFields   map[string]struct{}
 }
 
 // Log to loki
@@ -38,16 +67,37 @@ func (a *lokiAdapter) Log(log golog.Log) {
 		Timestamp: log.Timestamp,
 		Level:     log.Level.String(),
 		Labels:    make(map[string]string),
+		Metadata:  make(map[string]string),
 	}
 
 	for k, v := range a.cfg.Labels {
 		entry.Labels[k] = v
 	}
 
+	jsonFields := make(map[string]interface{})
+
 	if log.Data.Fields != nil {
 		for k, v := range log.Data.Fields {
-			if strVal, ok := a.convertToString(v); ok {
-				entry.Labels[k] = strVal
+			switch {
+			case a.isStreamLabel(k):
+				if strVal, ok := a.convertToString(v); ok {
+					entry.Labels[k] = strVal
+					continue
+				}
+				jsonFields[k] = v
+			case a.isMetadataLabel(k):
+				if strVal, ok := a.convertToString(v); ok {
+					entry.Metadata[k] = strVal
+					continue
+				}
+				jsonFields[k] = v
+			case a.isMessageField(k):
+				jsonFields[k] = v
+			default:
+				if len(a.messageFieldSet) > 0 {
+					a.warnUnknownFieldOnce(k)
+				}
+				jsonFields[k] = v
 			}
 		}
 	}
@@ -70,31 +120,41 @@ func (a *lokiAdapter) Log(log golog.Log) {
 		messageBuffer.WriteString(log.Data.Error.Error())
 	}
 
-	if log.Data.Fields != nil {
-		jsonFields := make(map[string]interface{})
-		for k, v := range log.Data.Fields {
-			if _, exists := entry.Labels[k]; !exists {
-				jsonFields[k] = v
+	if len(jsonFields) > 0 {
+		if jsonData, err := json.Marshal(jsonFields); err == nil {
+			if messageBuffer.Len() > 0 {
+				messageBuffer.WriteString(" | ")
 			}
-		}
-
-		if len(jsonFields) > 0 {
-			if jsonData, err := json.Marshal(jsonFields); err == nil {
-				if messageBuffer.Len() > 0 {
-					messageBuffer.WriteString(" | ")
-				}
 
-				messageBuffer.Write(jsonData)
-			}
+			messageBuffer.Write(jsonData)
 		}
 	}
 
 	entry.Line = messageBuffer.String()
 
+	a.closeMu.Lock()
+	defer a.closeMu.Unlock()
+	if a.closed {
+		return
+	}
+
+	if a.wal != nil {
+		segment, offset, err := a.wal.Append(entry)
+		if err != nil {
+			a.logError("Error writing to Loki WAL, dropping log: %v", err)
+			return
+		}
+		entry.walSegment = segment
+		entry.walOffset = offset
+	}
+
 	select {
 	case a.queue <- entry:
+		a.observer().IncEntriesEnqueued(1)
+		a.observer().SetQueueDepth(len(a.queue))
 	default:
-		fmt.Printf("Loki queue overflow, dropping log: %s\n", entry.Line)
+		a.logError("Loki queue overflow, dropping log: %s", entry.Line)
+		a.observer().IncEntriesDropped("overflow", 1)
 	}
 }
 
@@ -106,6 +166,45 @@ func (a *lokiAdapter) Format(log *golog.Log) {
 	}
 }
 
+// isStreamLabel reports whether field is whitelisted in cfg.StreamLabels.
+func (a *lokiAdapter) isStreamLabel(field string) bool {
+	_, ok := a.streamLabelSet[field]
+	return ok
+}
+
+// isMetadataLabel reports whether field is whitelisted in
+// cfg.MetadataLabels.
+func (a *lokiAdapter) isMetadataLabel(field string) bool {
+	_, ok := a.metadataLabelSet[field]
+	return ok
+}
+
+// isMessageField reports whether field is whitelisted in
+// cfg.MessageFields.
+func (a *lokiAdapter) isMessageField(field string) bool {
+	_, ok := a.messageFieldSet[field]
+	return ok
+}
+
+// warnUnknownFieldOnce reports field, once per field name for this
+// adapter's lifetime, as named in none of StreamLabels, MetadataLabels or
+// MessageFields. Deduping by name keeps a handful of dynamic or
+// undeclared field names from reproducing, in the error log, the exact
+// per-entry log-noise problem MessageFields exists to avoid in Loki's
+// label/metadata index.
+func (a *lokiAdapter) warnUnknownFieldOnce(field string) {
+	a.warnedFieldsMu.Lock()
+	_, seen := a.warnedFields[field]
+	if !seen {
+		a.warnedFields[field] = struct{}{}
+	}
+	a.warnedFieldsMu.Unlock()
+
+	if !seen {
+		a.logError("Loki field %q named in none of StreamLabels, MetadataLabels or MessageFields; embedding into message line", field)
+	}
+}
+
 // convertToString convert name to string
 func (a *lokiAdapter) convertToString(v interface{}) (string, bool) {
 	switch value := v.(type) {
@@ -131,14 +230,26 @@ func (a *lokiAdapter) batchProcessor() {
 	ticker := time.NewTicker(a.cfg.BatchInterval)
 	defer ticker.Stop()
 
+	queueCh := a.queue
+
 	for {
 		select {
-		case entry := <-a.queue:
+		case entry, ok := <-queueCh:
+			if !ok {
+				// a.queue was closed by Close; stop selecting on it so we
+				// don't spin on the now-ready zero-value receive.
+				queueCh = nil
+				continue
+			}
+
 			a.bufferMutex.Lock()
 			a.buffer = append(a.buffer, entry)
+			bufferLen := len(a.buffer)
 			a.bufferMutex.Unlock()
 
-			if len(a.buffer) >= a.cfg.BatchSize {
+			a.observer().SetBufferDepth(bufferLen)
+
+			if bufferLen >= a.cfg.BatchSize {
 				a.flush()
 			}
 
@@ -152,35 +263,75 @@ func (a *lokiAdapter) batchProcessor() {
 				a.flush()
 			}
 		case <-a.quit:
+			a.drainQueue()
+
 			a.bufferMutex.Lock()
 			bufferLen := len(a.buffer)
 			a.bufferMutex.Unlock()
 			if bufferLen > 0 {
-				a.flush()
+				ctx := a.shutdownCtx
+				if ctx == nil {
+					ctx = context.Background()
+				}
+				a.flushCtx(ctx)
 			}
 			return
 		}
 	}
 }
 
+// drainQueue moves whatever is already buffered in a.queue into a.buffer
+// without blocking. It is used on shutdown, once a.queue has been closed
+// and no further entries can arrive, to make sure nothing queued is lost
+// before the final flush.
+func (a *lokiAdapter) drainQueue() {
+	for {
+		select {
+		case entry, ok := <-a.queue:
+			if !ok {
+				return
+			}
+			a.bufferMutex.Lock()
+			a.buffer = append(a.buffer, entry)
+			a.bufferMutex.Unlock()
+		default:
+			return
+		}
+	}
+}
+
 // flush sending all flushed logs in loki
 func (a *lokiAdapter) flush() {
-	if len(a.buffer) == 0 {
-		return
-	}
+	a.flushCtx(context.Background())
+}
 
+// flushCtx is the context-aware core of flush; Flush(ctx) uses it directly
+// so a caller-supplied deadline bounds the send (and its retries).
+func (a *lokiAdapter) flushCtx(ctx context.Context) (bool, error) {
 	a.bufferMutex.Lock()
+	if len(a.buffer) == 0 {
+		a.bufferMutex.Unlock()
+		return true, nil
+	}
 	bufferCopy := make([]lokiLogEntry, len(a.buffer))
 	copy(bufferCopy, a.buffer)
 	a.buffer = a.buffer[:0]
 	a.bufferMutex.Unlock()
 
+	a.observer().SetBufferDepth(0)
+	a.observer().ObserveBatchSize(len(bufferCopy))
+
+	flushStart := time.Now()
+	defer func() {
+		a.observer().ObserveFlushDuration(time.Since(flushStart))
+	}()
+
 	streams := make(map[string]lokiStream)
 
 	for _, entry := range bufferCopy {
 		labelsJSON, err := json.Marshal(entry.Labels)
 		if err != nil {
-			fmt.Printf("Error marshalling labels: %s\n", err.Error())
+			a.logError("Error marshalling labels: %s", err.Error())
 			continue
 		}
 		labelsKey := string(labelsJSON)
@@ -189,14 +340,14 @@ func (a *lokiAdapter) flush() {
 		if !exists {
 			stream = lokiStream{
 				Stream: entry.Labels,
-				Values: make([][2]string, 0),
+				Values: make([]lokiValue, 0),
 			}
 		}
 
-		timestamp := entry.Timestamp.UnixNano()
-		stream.Values = append(stream.Values, [2]string{
-			fmt.Sprintf("%d", timestamp),
-			entry.Line,
+		stream.Values = append(stream.Values, lokiValue{
+			Timestamp: entry.Timestamp,
+			Line:      entry.Line,
+			Metadata:  entry.Metadata,
 		})
 
 		streams[labelsKey] = stream
@@ -210,101 +361,239 @@ func (a *lokiAdapter) flush() {
 		payload.Streams = append(payload.Streams, stream)
 	}
 
-	if ok := a.sendWithRetry(payload); ok {
+	ok, err := a.sendWithRetry(ctx, bufferCopy, payload)
+	if ok {
 		a.bufferMutex.Lock()
 		a.lastFlush = time.Now()
 		a.bufferMutex.Unlock()
+
+		a.ackWAL(bufferCopy)
+	}
+
+	return ok, err
+}
+
+// ackWAL acknowledges the highest WAL position among entries, if
+// persistence is enabled, allowing fully delivered segments to be reclaimed.
+func (a *lokiAdapter) ackWAL(entries []lokiLogEntry) {
+	if a.wal == nil || len(entries) == 0 {
+		return
+	}
+
+	segment, offset := entries[0].walSegment, entries[0].walOffset
+	for _, entry := range entries[1:] {
+		if entry.walSegment > segment || (entry.walSegment == segment && entry.walOffset > offset) {
+			segment, offset = entry.walSegment, entry.walOffset
+		}
+	}
+
+	if err := a.wal.Ack(segment, offset); err != nil {
+		a.logError("Error acknowledging Loki WAL: %v", err)
 	}
 }
 
-// sendWithRetry retry sending batch in Loki
-func (a *lokiAdapter) sendWithRetry(payload lokiPayload) bool {
-	for attempt := 0; attempt <= a.cfg.RetryCount; attempt++ {
+// sendWithRetry sends payload to Loki, retrying retryable failures with
+// exponential backoff and full jitter until it succeeds, hits a terminal
+// error, exhausts RetryMaxAttempts, or ctx is cancelled. A 429 response's
+// Retry-After, when present, replaces the next attempt's computed backoff
+// instead of being waited out in addition to it. entries is the
+// pre-grouping batch that produced payload, passed through only so
+// OnDropped can report it.
+func (a *lokiAdapter) sendWithRetry(ctx context.Context, entries []lokiLogEntry, payload lokiPayload) (bool, error) {
+	var lastErr error
+
+	// retryAfterOverride, when >= 0, is a server-requested Retry-After that
+	// replaces the next attempt's computed exponential backoff outright
+	// rather than being added on top of it. -1 means "no override, use
+	// retryDelay as normal".
+	retryAfterOverride := time.Duration(-1)
+
+	for attempt := 0; attempt < a.cfg.RetryMaxAttempts; attempt++ {
 		if attempt > 0 {
-			time.Sleep(a.cfg.RetryDelay * time.Duration(attempt))
+			a.observer().IncRetries()
+
+			delay := a.retryDelay(attempt)
+			if retryAfterOverride >= 0 {
+				delay = retryAfterOverride
+				retryAfterOverride = -1
+			}
+
+			if !sleepCtx(ctx, delay) {
+				lastErr = ctx.Err()
+				a.drop(entries, lastErr)
+				return false, lastErr
+			}
+		}
+
+		sendStart := time.Now()
+		result := a.send(ctx, payload)
+		a.observer().ObserveSendDuration(time.Since(sendStart))
+
+		if result.ok {
+			a.observer().IncBatchesSent("success")
+			return true, nil
+		}
+
+		lastErr = result.err
+
+		if !result.retryable {
+			a.observer().IncBatchesSent("4xx")
+			a.observer().IncEntriesDropped("4xx", len(entries))
+			a.logError("Loki rejected batch, dropping: %v", lastErr)
+			a.drop(entries, lastErr)
+			return false, lastErr
 		}
 
-		return a.send(payload)
+		a.observer().IncBatchesSent("retryable_error")
+
+		if result.retryAfter > 0 {
+			retryAfterOverride = result.retryAfter
+		}
 	}
 
-	fmt.Printf("Failed to send logs to Loki after %d attempts\n", a.cfg.RetryCount)
-	return true
+	a.observer().IncEntriesDropped("giveup", len(entries))
+	a.logError("Failed to send logs to Loki after %d attempts: %v", a.cfg.RetryMaxAttempts, lastErr)
+	a.drop(entries, lastErr)
+	return false, lastErr
 }
 
-// send sending batch in Loki
-func (a *lokiAdapter) send(payload lokiPayload) bool {
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		fmt.Printf("Error marshaling Loki payload: %v\n", err)
+// sleepCtx waits for d, returning false early if ctx is cancelled first.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
 		return false
 	}
+}
 
-	req, err := http.NewRequest("POST", a.cfg.Url, bytes.NewReader(jsonData))
-	if err != nil {
-		fmt.Printf("Error creating Loki request: %v\n", err)
-		return false
+// drop invokes the OnDropped hook, if configured, for a batch that could
+// not be delivered.
+func (a *lokiAdapter) drop(entries []lokiLogEntry, err error) {
+	if a.cfg.OnDropped == nil {
+		return
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	dropped := make([]DroppedEntry, len(entries))
+	for i, entry := range entries {
+		dropped[i] = entry.toDroppedEntry()
+	}
 
-	resp, err := a.client.Do(req)
-	if err != nil {
-		fmt.Printf("Error sending to Loki: %v\n", err)
-		return false
+	a.cfg.OnDropped(dropped, err)
+}
+
+// retryDelay computes the backoff for a given retry attempt: full jitter in
+// the range [0.8, 1.2] * min(RetryMaxBackoff, RetryMinBackoff*2^(attempt-1)).
+func (a *lokiAdapter) retryDelay(attempt int) time.Duration {
+	delay := a.cfg.RetryMinBackoff * time.Duration(uint64(1)<<uint(attempt-1))
+	if delay <= 0 || delay > a.cfg.RetryMaxBackoff {
+		delay = a.cfg.RetryMaxBackoff
 	}
-	defer resp.Body.Close()
 
+	jitter := 1 + (rand.Float64()*2-1)*0.2
+	return time.Duration(float64(delay) * jitter)
+}
+
+// sendOutcome classifies the result of a single send attempt so the retry
+// loop can tell a retryable failure (network error, 429, 5xx) from a
+// terminal one (any other 4xx, which Loki will never accept).
+type sendOutcome struct {
+	ok         bool
+	retryable  bool
+	retryAfter time.Duration
+	err        error
+}
+
+// classifyStatusCode turns an HTTP response into a sendOutcome, honoring
+// Retry-After on 429s.
+func classifyStatusCode(resp *http.Response) sendOutcome {
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		return true
+		return sendOutcome{ok: true}
 	}
 
-	fmt.Printf("Loki returned error status: %d\n", resp.StatusCode)
-	return false
+	err := fmt.Errorf("Loki returned error status: %d", resp.StatusCode)
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return sendOutcome{retryable: true, retryAfter: retryAfterDuration(resp), err: err}
+	}
+
+	return sendOutcome{retryable: false, err: err}
 }
 
-// NewLokiAdapter returns new LokiAdapter
-func NewLokiAdapter(cfg *LokiConfig) LokiAdapter {
-	adapter := &lokiAdapter{
-		cfg: cfg,
+// retryAfterDuration parses a Retry-After header expressed in seconds. It
+// returns 0 if the header is absent or malformed, letting the caller fall
+// back to its own backoff schedule.
+func retryAfterDuration(resp *http.Response) time.Duration {
+	header := resp.Header.Get("Retry-After")
+	if header == "" {
+		return 0
+	}
 
-		client: &http.Client{
-			Timeout: cfg.Timeout,
-			Transport: &http.Transport{
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 100,
-				IdleConnTimeout:     90 * time.Second,
-			},
-		},
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds < 0 {
+		return 0
+	}
 
-		queue:  make(chan lokiLogEntry, cfg.BatchSize*10),
-		quit:   make(chan struct{}),
-		buffer: make([]lokiLogEntry, 0, cfg.BatchSize),
+	return time.Duration(seconds) * time.Second
+}
 
-		lastFlush: time.Now(),
+// send sending batch in Loki
+func (a *lokiAdapter) send(ctx context.Context, payload lokiPayload) sendOutcome {
+	if a.cfg.Format == FormatProtobuf {
+		return a.sendProtobuf(ctx, payload)
 	}
 
-	adapter.wg.Add(1)
-	go adapter.batchProcessor()
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return sendOutcome{err: fmt.Errorf("marshaling Loki payload: %w", err)}
+	}
 
-	return adapter
+	req, err := http.NewRequestWithContext(ctx, "POST", a.cfg.Url, bytes.NewReader(jsonData))
+	if err != nil {
+		return sendOutcome{err: fmt.Errorf("creating Loki request: %w", err)}
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	a.applyAuthHeaders(req)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return sendOutcome{retryable: true, err: fmt.Errorf("sending to Loki: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	return classifyStatusCode(resp)
+}
+
+// NewLokiAdapter returns new LokiAdapter
+func NewLokiAdapter(cfg *LokiConfig) LokiAdapter {
+	return newLokiAdapter(cfg)
 }
 
 // NewDefaultLokiAdapter returns new LokiAdapter with default config
 func NewDefaultLokiAdapter(url string) LokiAdapter {
-	cfg := defaultLokiConfig(url)
+	return newLokiAdapter(defaultLokiConfig(url))
+}
 
+// newLokiAdapter builds and starts a lokiAdapter, opening and replaying its
+// write-ahead log first when Persistence is configured.
+func newLokiAdapter(cfg *LokiConfig) *lokiAdapter {
 	adapter := &lokiAdapter{
 		cfg: cfg,
 
 		client: &http.Client{
-			Timeout: cfg.Timeout,
-			Transport: &http.Transport{
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 100,
-				IdleConnTimeout:     90 * time.Second,
-			},
+			Timeout:   cfg.Timeout,
+			Transport: buildTransport(cfg),
 		},
 
+		streamLabelSet:   stringSet(cfg.StreamLabels),
+		metadataLabelSet: stringSet(cfg.MetadataLabels),
+		messageFieldSet:  stringSet(cfg.MessageFields),
+		warnedFields:     make(map[string]struct{}),
+
 		queue:  make(chan lokiLogEntry, cfg.BatchSize*10),
 		quit:   make(chan struct{}),
 		buffer: make([]lokiLogEntry, 0, cfg.BatchSize),
@@ -312,8 +601,49 @@ func NewDefaultLokiAdapter(url string) LokiAdapter {
 		lastFlush: time.Now(),
 	}
 
+	if cfg.Persistence != nil {
+		wal, err := openWALStore(cfg.Persistence, adapter.logError)
+		if err != nil {
+			adapter.logError("Error opening Loki WAL, persistence disabled: %v", err)
+		} else {
+			adapter.wal = wal
+			adapter.replayWAL()
+		}
+	}
+
 	adapter.wg.Add(1)
 	go adapter.batchProcessor()
 
 	return adapter
 }
+
+// stringSet builds a membership set out of names, used to turn
+// LokiConfig's StreamLabels/MetadataLabels slices into O(1) lookups.
+func stringSet(names []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		set[name] = struct{}{}
+	}
+	return set
+}
+
+// replayWAL re-queues everything the WAL has that hasn't been acknowledged
+// yet, so logs survive a crash or restart while Loki was unreachable.
+// Replayed entries that do not fit in the queue are reported and dropped.
+func (a *lokiAdapter) replayWAL() {
+	err := a.wal.Replay(func(entry lokiLogEntry, segment uint64, offset int64) {
+		entry.walSegment = segment
+		entry.walOffset = offset
+
+		select {
+		case a.queue <- entry:
+			a.observer().IncEntriesEnqueued(1)
+		default:
+			a.logError("Loki queue overflow replaying WAL, dropping log: %s", entry.Line)
+			a.observer().IncEntriesDropped("overflow", 1)
+		}
+	})
+	if err != nil {
+		a.logError("Error replaying Loki WAL: %v", err)
+	}
+}
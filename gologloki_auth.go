@@ -0,0 +1,94 @@
+package gologloki
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// applyAuthHeaders sets authentication and tenancy headers shared by both
+// the JSON and protobuf send paths.
+func (a *lokiAdapter) applyAuthHeaders(req *http.Request) {
+	if a.cfg.BasicAuth != nil {
+		req.SetBasicAuth(a.cfg.BasicAuth.Username, a.cfg.BasicAuth.Password)
+	}
+
+	if token := a.bearerToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	if a.cfg.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", a.cfg.TenantID)
+	}
+
+	for k, v := range a.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+}
+
+// bearerToken resolves the bearer token to use for this send. When
+// BearerTokenFile is set it is re-read on every call so rotated tokens are
+// picked up without restarting the adapter.
+func (a *lokiAdapter) bearerToken() string {
+	if a.cfg.BearerTokenFile != "" {
+		data, err := os.ReadFile(a.cfg.BearerTokenFile)
+		if err != nil {
+			a.logError("Error reading Loki bearer token file: %v", err)
+			return a.cfg.BearerToken
+		}
+		return strings.TrimSpace(string(data))
+	}
+
+	return a.cfg.BearerToken
+}
+
+// buildTransport builds the http.Transport used by the adapter, applying
+// TLS options when configured. On TLS setup errors it logs and falls back
+// to a transport with default TLS settings rather than failing construction.
+func buildTransport(cfg *LokiConfig) *http.Transport {
+	transport := &http.Transport{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 100,
+		IdleConnTimeout:     90 * time.Second,
+	}
+
+	if cfg.TLS == nil {
+		return transport
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.TLS.InsecureSkipVerify,
+		ServerName:         cfg.TLS.ServerName,
+	}
+
+	if cfg.TLS.CAFile != "" {
+		caCert, err := os.ReadFile(cfg.TLS.CAFile)
+		if err != nil {
+			logConfigError(cfg, "Error reading Loki CA file: %v", err)
+			return transport
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			logConfigError(cfg, "Error parsing Loki CA file: %s", cfg.TLS.CAFile)
+			return transport
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.TLS.CertFile != "" && cfg.TLS.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			logConfigError(cfg, "Error loading Loki client certificate: %v", err)
+			return transport
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+
+	return transport
+}
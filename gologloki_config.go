@@ -5,6 +5,34 @@ import (
 	"time"
 )
 
+// LokiEncoding selects the wire format used to push logs to Loki.
+type LokiEncoding int
+
+const (
+	// FormatJSON posts the plain JSON push payload (default).
+	FormatJSON LokiEncoding = iota
+	// FormatProtobuf posts a snappy-compressed protobuf push payload, which
+	// Loki ingests with noticeably less overhead than JSON.
+	FormatProtobuf
+)
+
+// LokiBasicAuth carries HTTP Basic Auth credentials for the push request.
+type LokiBasicAuth struct {
+	Username string
+	Password string
+}
+
+// LokiTLSConfig configures the TLS transport used to reach Loki.
+type LokiTLSConfig struct {
+	InsecureSkipVerify bool
+
+	CAFile   string
+	CertFile string
+	KeyFile  string
+
+	ServerName string
+}
+
 // LokiConfig core config for adapter
 type LokiConfig struct {
 	Enable bool
@@ -14,11 +42,77 @@ type LokiConfig struct {
 	Url    string
 	Labels map[string]string
 
+	// StreamLabels, MetadataLabels and MessageFields partition the field
+	// names seen in golog.Log.Data.Fields. StreamLabels become Loki stream
+	// labels (indexed; keep this list short and low-cardinality, since
+	// every distinct combination of values opens a new stream).
+	// MetadataLabels ride along as Loki's per-entry structured metadata
+	// (searchable, not indexed — safe for high-cardinality values like
+	// request or trace IDs). MessageFields, and any field named in none of
+	// the three lists, are JSON-embedded into the log line, same as
+	// before. The zero value of all three is empty, so by default every
+	// field lands in the message instead of becoming a label — this
+	// adapter does not promote arbitrary fields to labels unless asked to.
+	//
+	// MessageFields does not change where a field ends up (it always
+	// lands in the message), but once it is non-empty, a field named in
+	// none of the three lists is treated as a schema drift: it is still
+	// embedded in the message so nothing is lost, but ErrorLog is notified
+	// once per field name (not once per log line) so callers who bothered
+	// to enumerate MessageFields learn when a new, unlisted field shows up
+	// without reproducing the per-entry noise MessageFields exists to
+	// avoid.
+	StreamLabels   []string
+	MetadataLabels []string
+	MessageFields  []string
+
+	Format LokiEncoding
+
+	// BasicAuth, BearerToken/BearerTokenFile and TenantID configure request
+	// authentication; at most one of BasicAuth and the bearer token fields
+	// should be set. BearerTokenFile is re-read on every send so rotated
+	// tokens are picked up without restarting the adapter.
+	BasicAuth       *LokiBasicAuth
+	BearerToken     string
+	BearerTokenFile string
+	TenantID        string
+	Headers         map[string]string
+
+	TLS *LokiTLSConfig
+
+	// Persistence enables an on-disk write-ahead log so entries survive a
+	// crash or extended Loki outage instead of living only in the in-memory
+	// queue and buffer. Nil disables it (the default, best-effort memory
+	// only behavior).
+	Persistence *LokiPersistenceConfig
+
 	BatchSize     int
 	BatchInterval time.Duration
 
-	RetryCount int
-	RetryDelay time.Duration
+	// RetryMinBackoff and RetryMaxBackoff bound the exponential backoff
+	// applied between retries (with full jitter); RetryMaxAttempts caps how
+	// many send attempts a batch gets in total, the first send included,
+	// before it is dropped.
+	RetryMinBackoff  time.Duration
+	RetryMaxBackoff  time.Duration
+	RetryMaxAttempts int
+
+	// OnDropped, if set, is invoked with a batch that could not be
+	// delivered after exhausting retries or hitting a terminal error, so
+	// callers can wire up dead-letter handling instead of losing data
+	// silently.
+	OnDropped func(entries []DroppedEntry, err error)
+
+	// Observer, if set, receives counters and histograms about the
+	// adapter's operation (see the Observer doc comment for the full
+	// list). Nil disables metrics collection.
+	Observer Observer
+
+	// ErrorLog, if set, receives the error strings this adapter would
+	// otherwise print to stdout (queue overflow, send failures, dropped
+	// batches, ...), so library users can route them through their own
+	// logging system instead of polluting stdout in production.
+	ErrorLog func(msg string)
 
 	Timeout time.Duration
 }
@@ -34,11 +128,14 @@ func defaultLokiConfig(url string) *LokiConfig {
 			"job": "app_logs",
 		},
 
+		Format: FormatJSON,
+
 		BatchSize:     100,
 		BatchInterval: 5 * time.Second,
 
-		RetryCount: 3,
-		RetryDelay: 1 * time.Second,
+		RetryMinBackoff:  500 * time.Millisecond,
+		RetryMaxBackoff:  5 * time.Minute,
+		RetryMaxAttempts: 10,
 
 		Timeout: 10 * time.Second,
 	}
@@ -0,0 +1,45 @@
+package gologloki
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/memUsins/golog"
+)
+
+// TestLogWarnsUnknownFieldOnce checks that a field named in none of
+// StreamLabels, MetadataLabels or MessageFields is reported through
+// ErrorLog once per field name, not once per log line, so real traffic
+// with a handful of undeclared field names doesn't reproduce per-entry
+// log noise in the error channel.
+func TestLogWarnsUnknownFieldOnce(t *testing.T) {
+	srv := httptest.NewServer(nil)
+	defer srv.Close()
+
+	var warnings []string
+
+	cfg := defaultLokiConfig(srv.URL)
+	cfg.MessageFields = []string{"known"}
+	cfg.ErrorLog = func(msg string) {
+		warnings = append(warnings, msg)
+	}
+
+	adapter := newLokiAdapter(cfg)
+	defer adapter.Close(context.Background())
+
+	for i := 0; i < 5; i++ {
+		adapter.Log(golog.Log{
+			Timestamp: time.Now(),
+			Level:     golog.DebugLevel,
+			Data: golog.LogData{
+				Fields: map[string]interface{}{"unlisted": i},
+			},
+		})
+	}
+
+	if len(warnings) != 1 {
+		t.Errorf("got %d ErrorLog warnings for a repeated unlisted field, want exactly 1: %v", len(warnings), warnings)
+	}
+}
@@ -0,0 +1,61 @@
+package gologloki
+
+import "context"
+
+// Close stops accepting new logs and waits for the batch processor
+// goroutine to drain and flush whatever was already queued. It is safe to
+// call more than once; later calls are no-ops. ctx bounds both the wait and
+// the final flush the goroutine performs on its way out (including any
+// retries), so a deadline that elapses before the flush finishes makes
+// Close return ctx.Err() instead of blocking forever — the in-flight send
+// is itself cancelled by ctx, it does not keep running in the background.
+// The on-disk WAL, when enabled, is always closed before Close returns,
+// even when ctx expires first.
+func (a *lokiAdapter) Close(ctx context.Context) error {
+	a.closeOnce.Do(func() {
+		a.closeMu.Lock()
+		a.closed = true
+		a.shutdownCtx = ctx
+		close(a.queue)
+		a.closeMu.Unlock()
+
+		close(a.quit)
+
+		done := make(chan struct{})
+		go func() {
+			a.wg.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			a.closeErr = ctx.Err()
+		}
+
+		if a.wal != nil {
+			if walErr := a.wal.Close(); walErr != nil && a.closeErr == nil {
+				a.closeErr = walErr
+			}
+		}
+	})
+
+	return a.closeErr
+}
+
+// Flush sends whatever is currently buffered without tearing the adapter
+// down, e.g. as a synchronous checkpoint after panic recovery or at the end
+// of a batch job. The send, including retries, is bounded by ctx.
+func (a *lokiAdapter) Flush(ctx context.Context) error {
+	_, err := a.flushCtx(ctx)
+	return err
+}
+
+// WALDroppedBytes returns the cumulative bytes discarded by the WAL's
+// MaxBytes eviction, or 0 if Persistence is disabled.
+func (a *lokiAdapter) WALDroppedBytes() int64 {
+	if a.wal == nil {
+		return 0
+	}
+	return a.wal.DroppedBytes()
+}
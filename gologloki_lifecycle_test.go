@@ -0,0 +1,70 @@
+package gologloki
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/memUsins/golog"
+)
+
+// TestCloseBoundsStuckSend verifies that Close(ctx) returns once ctx expires
+// even when the shutdown flush is stuck sending to an unresponsive Loki, and
+// that the flush's HTTP request is actually cancelled rather than left
+// running in the background (the ctx it uses to flush is the same one
+// passed to Close, not an unbounded context.Background()).
+func TestCloseBoundsStuckSend(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer func() {
+		close(block) // release the blocked handler before srv.Close waits on it
+		srv.Close()
+	}()
+
+	cfg := defaultLokiConfig(srv.URL)
+	cfg.BatchSize = 10
+	cfg.BatchInterval = time.Hour
+	cfg.RetryMaxAttempts = 1000
+	cfg.RetryMinBackoff = time.Hour
+	cfg.RetryMaxBackoff = time.Hour
+	cfg.Timeout = time.Hour
+
+	adapter := newLokiAdapter(cfg)
+
+	adapter.Log(golog.Log{Timestamp: time.Now(), Level: golog.DebugLevel, Message: "hi"})
+	time.Sleep(50 * time.Millisecond) // let batchProcessor move it into the buffer
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := adapter.Close(ctx)
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Close() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed > 2*time.Second {
+		t.Fatalf("Close() took %v, want it bounded by ctx's 200ms deadline", elapsed)
+	}
+
+	// The flush's HTTP request is cancelled by the same ctx, so the batch
+	// processor goroutine should exit shortly after Close returns instead
+	// of leaking forever on the blocked send.
+	leaked := make(chan struct{})
+	go func() {
+		adapter.wg.Wait()
+		close(leaked)
+	}()
+
+	select {
+	case <-leaked:
+	case <-time.After(time.Second):
+		t.Fatal("batch processor goroutine did not exit after Close's ctx expired")
+	}
+}
@@ -0,0 +1,157 @@
+package gologloki
+
+import (
+	"context"
+	"fmt"
+	"github.com/memUsins/golog"
+)
+
+// LokiTargetSelector describes which logs a LokiTarget accepts. A log must
+// satisfy all three conditions to be routed to the target.
+type LokiTargetSelector struct {
+	// LoggerNames matches against log.Data.Name; "*" matches everything.
+	// An empty slice also matches everything.
+	LoggerNames []string
+
+	// MinLevel is the minimum level this target accepts.
+	MinLevel golog.Level
+
+	// FieldMatch requires log.Data.Fields to contain each key with an
+	// equal value (compared as a string). An empty map matches everything.
+	FieldMatch map[string]string
+}
+
+// matches reports whether log should be routed to a target with this
+// selector.
+func (s LokiTargetSelector) matches(log golog.Log) bool {
+	return s.matchesLoggerName(log) && s.MinLevel.IsEnabled(log.Level) && s.matchesFields(log)
+}
+
+func (s LokiTargetSelector) matchesLoggerName(log golog.Log) bool {
+	if len(s.LoggerNames) == 0 {
+		return true
+	}
+
+	for _, name := range s.LoggerNames {
+		if name == "*" || name == log.Data.Name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s LokiTargetSelector) matchesFields(log golog.Log) bool {
+	if len(s.FieldMatch) == 0 {
+		return true
+	}
+
+	if log.Data.Fields == nil {
+		return false
+	}
+
+	for name, want := range s.FieldMatch {
+		got, ok := log.Data.Fields[name]
+		if !ok || fmt.Sprintf("%v", got) != want {
+			return false
+		}
+	}
+
+	return true
+}
+
+// LokiTarget is one named push destination within a MultiLokiConfig. Config
+// carries everything a standalone LokiAdapter would need (Url, Labels,
+// auth, batching, ...); Selector decides which logs are routed to it.
+type LokiTarget struct {
+	Name     string
+	Config   *LokiConfig
+	Selector LokiTargetSelector
+}
+
+// MultiLokiConfig fans out logs to several named Loki targets from a single
+// adapter, e.g. sending debug streams to a local Loki and warn+ to Grafana
+// Cloud from one process.
+type MultiLokiConfig struct {
+	Targets []LokiTarget
+}
+
+// multiLokiAdapter implements LokiAdapter by dispatching each log to every
+// target whose Selector matches. Each target owns its own goroutine,
+// buffer and HTTP client, so a slow or broken endpoint cannot back-pressure
+// the others.
+type multiLokiAdapter struct {
+	targets []multiLokiTargetRuntime
+}
+
+type multiLokiTargetRuntime struct {
+	name     string
+	selector LokiTargetSelector
+	adapter  *lokiAdapter
+}
+
+// Log dispatches log to every target whose Selector matches.
+func (m *multiLokiAdapter) Log(log golog.Log) {
+	for _, target := range m.targets {
+		if target.selector.matches(log) {
+			target.adapter.Log(log)
+		}
+	}
+}
+
+// Close closes every target, returning the first error encountered (after
+// attempting to close the rest).
+func (m *multiLokiAdapter) Close(ctx context.Context) error {
+	var firstErr error
+
+	for _, target := range m.targets {
+		if err := target.adapter.Close(ctx); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("target %q: %w", target.name, err)
+		}
+	}
+
+	return firstErr
+}
+
+// Flush flushes every target, returning the first error encountered (after
+// attempting to flush the rest).
+func (m *multiLokiAdapter) Flush(ctx context.Context) error {
+	var firstErr error
+
+	for _, target := range m.targets {
+		if err := target.adapter.Flush(ctx); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("target %q: %w", target.name, err)
+		}
+	}
+
+	return firstErr
+}
+
+// WALDroppedBytes returns the sum of WALDroppedBytes across every target.
+func (m *multiLokiAdapter) WALDroppedBytes() int64 {
+	var total int64
+
+	for _, target := range m.targets {
+		total += target.adapter.WALDroppedBytes()
+	}
+
+	return total
+}
+
+// NewMultiLokiAdapter returns a LokiAdapter that fans logs out to several
+// named Loki targets, each with its own selection criteria.
+func NewMultiLokiAdapter(cfg *MultiLokiConfig) LokiAdapter {
+	m := &multiLokiAdapter{
+		targets: make([]multiLokiTargetRuntime, 0, len(cfg.Targets)),
+	}
+
+	for _, target := range cfg.Targets {
+		m.targets = append(m.targets, multiLokiTargetRuntime{
+			name:     target.Name,
+			selector: target.Selector,
+			adapter:  NewLokiAdapter(target.Config).(*lokiAdapter),
+		})
+	}
+
+	return m
+}
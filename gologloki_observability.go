@@ -0,0 +1,93 @@
+package gologloki
+
+import (
+	"fmt"
+	"time"
+)
+
+// Observer receives metrics about the adapter's operation so they can be
+// exported to Prometheus (or any other backend) under names such as
+// gologloki_entries_enqueued_total, gologloki_entries_dropped_total{reason},
+// gologloki_batches_sent_total{status}, gologloki_batch_size,
+// gologloki_flush_duration_seconds, gologloki_send_duration_seconds,
+// gologloki_retries_total, and queue/buffer depth gauges. A nil Observer
+// (the default) disables metrics collection entirely.
+type Observer interface {
+	// IncEntriesEnqueued counts entries accepted onto the in-memory queue.
+	IncEntriesEnqueued(count int)
+
+	// IncEntriesDropped counts entries that were not delivered, tagged
+	// with why: "overflow", "4xx" or "giveup".
+	IncEntriesDropped(reason string, count int)
+
+	// IncBatchesSent counts one send attempt's outcome: "success", "4xx"
+	// for a non-retryable rejection, or "retryable_error" for anything
+	// that will be retried (network error, 429, 5xx).
+	IncBatchesSent(status string)
+
+	// ObserveBatchSize records how many entries were in a flushed batch.
+	ObserveBatchSize(size int)
+
+	// ObserveFlushDuration records how long a full flush (including
+	// retries) took.
+	ObserveFlushDuration(d time.Duration)
+
+	// ObserveSendDuration records how long a single HTTP send attempt
+	// took.
+	ObserveSendDuration(d time.Duration)
+
+	// IncRetries counts one retry attempt.
+	IncRetries()
+
+	// SetQueueDepth reports the current number of entries buffered in the
+	// in-memory queue channel.
+	SetQueueDepth(depth int)
+
+	// SetBufferDepth reports the current number of entries accumulated in
+	// the pending batch buffer.
+	SetBufferDepth(depth int)
+}
+
+// noopObserver is the Observer used when LokiConfig.Observer is nil.
+type noopObserver struct{}
+
+func (noopObserver) IncEntriesEnqueued(int)             {}
+func (noopObserver) IncEntriesDropped(string, int)      {}
+func (noopObserver) IncBatchesSent(string)              {}
+func (noopObserver) ObserveBatchSize(int)               {}
+func (noopObserver) ObserveFlushDuration(time.Duration) {}
+func (noopObserver) ObserveSendDuration(time.Duration)  {}
+func (noopObserver) IncRetries()                        {}
+func (noopObserver) SetQueueDepth(int)                  {}
+func (noopObserver) SetBufferDepth(int)                 {}
+
+var defaultObserver Observer = noopObserver{}
+
+// observer returns cfg.Observer, falling back to a no-op implementation so
+// call sites never need a nil check.
+func (a *lokiAdapter) observer() Observer {
+	if a.cfg.Observer != nil {
+		return a.cfg.Observer
+	}
+	return defaultObserver
+}
+
+// logError reports an operational error. If cfg.ErrorLog is set it receives
+// the formatted message instead of the message going to stdout, so library
+// users can route it through their own logging system.
+func (a *lokiAdapter) logError(format string, args ...interface{}) {
+	logConfigError(a.cfg, format, args...)
+}
+
+// logConfigError is the cfg-only core of logError, for call sites (such as
+// buildTransport) that run before a lokiAdapter exists to call a method on.
+func logConfigError(cfg *LokiConfig, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+
+	if cfg.ErrorLog != nil {
+		cfg.ErrorLog(msg)
+		return
+	}
+
+	fmt.Println(msg)
+}
@@ -0,0 +1,470 @@
+package gologloki
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LokiPersistenceConfig enables a disk-backed write-ahead log. Every entry
+// accepted by Log is appended to it before being queued, and a flush only
+// acknowledges (and permits reclaiming) the bytes it actually delivered, so
+// logs survive a crash or an extended Loki outage instead of being
+// best-effort in-memory only.
+type LokiPersistenceConfig struct {
+	// Dir is where WAL segments and the checkpoint file are stored.
+	Dir string
+
+	// MaxBytes bounds how much unacknowledged WAL data is kept on disk.
+	// Once exceeded, the oldest segments are dropped (and their bytes
+	// counted in DroppedBytes) rather than blocking Log.
+	MaxBytes int64
+
+	// SyncEvery is the minimum time between fsyncs of the active segment:
+	// an Append fsyncs it once SyncEvery has passed since the last fsync
+	// (every Append when SyncEvery <= 0). There is no background timer, so
+	// writes made since the last fsync stay unsynced until a later Append
+	// comes due. The checkpoint file is always fsynced
+	// immediately, since acknowledging data we didn't durably advance past
+	// would defeat the point.
+	SyncEvery time.Duration
+}
+
+// walSegmentMaxBytes is the size at which the WAL rolls to a new segment
+// file, independent of MaxBytes (which bounds total retained size).
+const walSegmentMaxBytes int64 = 8 << 20 // 8 MiB
+
+// walEntry is the on-disk, position-independent form of a lokiLogEntry.
+type walEntry struct {
+	Timestamp time.Time
+	Line      string
+	Level     string
+	Labels    map[string]string
+	Metadata  map[string]string
+}
+
+type walSegmentInfo struct {
+	id   uint64
+	size int64
+}
+
+// walStore is a segmented, append-only write-ahead log for lokiLogEntry
+// values, used to back LokiConfig.Persistence.
+type walStore struct {
+	dir       string
+	maxBytes  int64
+	syncEvery time.Duration
+
+	mu       sync.Mutex
+	segments []walSegmentInfo
+
+	activeID   uint64
+	activeFile *os.File
+	activeSize int64
+	lastSync   time.Time
+
+	checkpointSegment uint64
+	checkpointOffset  int64
+
+	droppedBytes int64
+
+	// errLog reports operational errors (a dropped or corrupt segment)
+	// through the owning adapter's cfg.ErrorLog instead of stdout, so WAL
+	// problems reach the same sink as every other operational error.
+	errLog func(format string, args ...interface{})
+}
+
+// openWALStore opens (creating if necessary) the WAL under cfg.Dir,
+// loading its checkpoint and segment inventory. errLog receives operational
+// errors encountered after this call (segment drops, replay corruption);
+// openWALStore's own errors are returned directly instead, since the caller
+// hasn't decided yet whether persistence is usable.
+func openWALStore(cfg *LokiPersistenceConfig, errLog func(format string, args ...interface{})) (*walStore, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating WAL dir: %w", err)
+	}
+
+	w := &walStore{
+		dir:       cfg.Dir,
+		maxBytes:  cfg.MaxBytes,
+		syncEvery: cfg.SyncEvery,
+		errLog:    errLog,
+	}
+
+	if err := w.loadCheckpoint(); err != nil {
+		return nil, err
+	}
+
+	if err := w.loadSegments(); err != nil {
+		return nil, err
+	}
+
+	if err := w.openActiveSegment(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *walStore) checkpointPath() string {
+	return filepath.Join(w.dir, "checkpoint")
+}
+
+func (w *walStore) segmentPath(id uint64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%020d.wal", id))
+}
+
+func (w *walStore) loadCheckpoint() error {
+	data, err := os.ReadFile(w.checkpointPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("reading WAL checkpoint: %w", err)
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return nil
+	}
+
+	segment, err1 := strconv.ParseUint(fields[0], 10, 64)
+	offset, err2 := strconv.ParseInt(fields[1], 10, 64)
+	if err1 != nil || err2 != nil {
+		return nil
+	}
+
+	w.checkpointSegment = segment
+	w.checkpointOffset = offset
+	return nil
+}
+
+func (w *walStore) saveCheckpoint() error {
+	f, err := os.Create(w.checkpointPath())
+	if err != nil {
+		return fmt.Errorf("writing WAL checkpoint: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintf(f, "%d %d\n", w.checkpointSegment, w.checkpointOffset); err != nil {
+		return fmt.Errorf("writing WAL checkpoint: %w", err)
+	}
+
+	return f.Sync()
+}
+
+func (w *walStore) loadSegments() error {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return fmt.Errorf("reading WAL dir: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".wal") {
+			continue
+		}
+
+		idStr := strings.TrimSuffix(entry.Name(), ".wal")
+		id, err := strconv.ParseUint(idStr, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf("stat WAL segment %d: %w", id, err)
+		}
+
+		w.segments = append(w.segments, walSegmentInfo{id: id, size: info.Size()})
+	}
+
+	sort.Slice(w.segments, func(i, j int) bool { return w.segments[i].id < w.segments[j].id })
+
+	return nil
+}
+
+func (w *walStore) openActiveSegment() error {
+	id := uint64(1)
+	if len(w.segments) > 0 {
+		id = w.segments[len(w.segments)-1].id
+	} else {
+		w.segments = append(w.segments, walSegmentInfo{id: id})
+	}
+
+	f, err := os.OpenFile(w.segmentPath(id), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening WAL segment %d: %w", id, err)
+	}
+
+	w.activeID = id
+	w.activeFile = f
+	w.activeSize = w.segments[len(w.segments)-1].size
+	w.lastSync = time.Now()
+
+	return nil
+}
+
+// totalBytes sums the on-disk size of every tracked segment.
+func (w *walStore) totalBytes() int64 {
+	var total int64
+	for _, s := range w.segments {
+		total += s.size
+	}
+	return total
+}
+
+// Append serializes entry and appends it to the active WAL segment,
+// returning its position. It rolls to a new segment once the active one
+// reaches walSegmentMaxBytes, and evicts oldest segments once MaxBytes is
+// exceeded (counting their bytes as dropped) rather than ever blocking.
+func (w *walStore) Append(entry lokiLogEntry) (segment uint64, offset int64, err error) {
+	data, err := encodeWALEntry(entry)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.activeSize > 0 && w.activeSize+int64(len(data)) > walSegmentMaxBytes {
+		if err := w.rollSegmentLocked(); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	offset = w.activeSize
+
+	if _, err := w.activeFile.Write(data); err != nil {
+		return 0, 0, fmt.Errorf("writing WAL entry: %w", err)
+	}
+	w.activeSize += int64(len(data))
+	w.segments[len(w.segments)-1].size = w.activeSize
+
+	if w.syncEvery <= 0 || time.Since(w.lastSync) >= w.syncEvery {
+		if err := w.activeFile.Sync(); err != nil {
+			return 0, 0, fmt.Errorf("syncing WAL segment: %w", err)
+		}
+		w.lastSync = time.Now()
+	}
+
+	w.enforceMaxBytesLocked()
+
+	return w.activeID, offset, nil
+}
+
+func (w *walStore) rollSegmentLocked() error {
+	if err := w.activeFile.Close(); err != nil {
+		return fmt.Errorf("closing WAL segment %d: %w", w.activeID, err)
+	}
+
+	id := w.activeID + 1
+	f, err := os.OpenFile(w.segmentPath(id), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening WAL segment %d: %w", id, err)
+	}
+
+	w.segments = append(w.segments, walSegmentInfo{id: id})
+	w.activeID = id
+	w.activeFile = f
+	w.activeSize = 0
+	w.lastSync = time.Now()
+
+	return nil
+}
+
+// enforceMaxBytesLocked drops the oldest non-active segments until total
+// size is within MaxBytes, counting their size as dropped. Called with mu
+// held.
+func (w *walStore) enforceMaxBytesLocked() {
+	if w.maxBytes <= 0 {
+		return
+	}
+
+	for w.totalBytes() > w.maxBytes && len(w.segments) > 1 && w.segments[0].id != w.activeID {
+		oldest := w.segments[0]
+		if err := os.Remove(w.segmentPath(oldest.id)); err != nil && !os.IsNotExist(err) {
+			w.errLog("Error dropping WAL segment %d: %v", oldest.id, err)
+			return
+		}
+
+		w.droppedBytes += oldest.size
+		w.segments = w.segments[1:]
+
+		if oldest.id >= w.checkpointSegment {
+			w.checkpointSegment = oldest.id + 1
+			w.checkpointOffset = 0
+		}
+	}
+}
+
+// Ack records that everything up to (segment, offset) has been durably
+// delivered to Loki, then deletes any segment that is now fully
+// acknowledged.
+func (w *walStore) Ack(segment uint64, offset int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if segment < w.checkpointSegment || (segment == w.checkpointSegment && offset <= w.checkpointOffset) {
+		return nil
+	}
+
+	w.checkpointSegment = segment
+	w.checkpointOffset = offset
+
+	if err := w.saveCheckpoint(); err != nil {
+		return err
+	}
+
+	kept := w.segments[:0]
+	for _, s := range w.segments {
+		if s.id < w.checkpointSegment && s.id != w.activeID {
+			if err := os.Remove(w.segmentPath(s.id)); err != nil && !os.IsNotExist(err) {
+				w.errLog("Error removing acknowledged WAL segment %d: %v", s.id, err)
+				kept = append(kept, s)
+				continue
+			}
+			continue
+		}
+		kept = append(kept, s)
+	}
+	w.segments = kept
+
+	return nil
+}
+
+// DroppedBytes returns the cumulative bytes discarded by MaxBytes eviction.
+func (w *walStore) DroppedBytes() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.droppedBytes
+}
+
+// Replay feeds every entry at or after the checkpoint position to fn, in
+// append order, so a restarted adapter can re-queue work it hadn't
+// delivered yet. The entry that starts exactly at the checkpoint is fed
+// again too, even though the Ack that set the checkpoint covered it.
+func (w *walStore) Replay(fn func(entry lokiLogEntry, segment uint64, offset int64)) error {
+	w.mu.Lock()
+	segments := append([]walSegmentInfo(nil), w.segments...)
+	checkpointSegment, checkpointOffset := w.checkpointSegment, w.checkpointOffset
+	w.mu.Unlock()
+
+	for _, s := range segments {
+		if s.id < checkpointSegment {
+			continue
+		}
+
+		startOffset := int64(0)
+		if s.id == checkpointSegment {
+			startOffset = checkpointOffset
+		}
+
+		if err := w.replaySegment(s.id, startOffset, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *walStore) replaySegment(id uint64, startOffset int64, fn func(entry lokiLogEntry, segment uint64, offset int64)) error {
+	f, err := os.Open(w.segmentPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("opening WAL segment %d: %w", id, err)
+	}
+	defer f.Close()
+
+	if startOffset > 0 {
+		if _, err := f.Seek(startOffset, io.SeekStart); err != nil {
+			return fmt.Errorf("seeking WAL segment %d: %w", id, err)
+		}
+	}
+
+	reader := bufio.NewReader(f)
+	offset := startOffset
+
+	for {
+		entry, n, err := decodeWALEntry(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			w.errLog("Error decoding WAL segment %d at offset %d, stopping replay: %v", id, offset, err)
+			break
+		}
+
+		fn(entry, id, offset)
+		offset += n
+	}
+
+	return nil
+}
+
+// Close closes the active segment file.
+func (w *walStore) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.activeFile.Close()
+}
+
+// encodeWALEntry gob-encodes entry with a 4-byte big-endian length prefix.
+func encodeWALEntry(entry lokiLogEntry) ([]byte, error) {
+	var body bytes.Buffer
+	if err := gob.NewEncoder(&body).Encode(walEntry{
+		Timestamp: entry.Timestamp,
+		Line:      entry.Line,
+		Level:     entry.Level,
+		Labels:    entry.Labels,
+		Metadata:  entry.Metadata,
+	}); err != nil {
+		return nil, fmt.Errorf("encoding WAL entry: %w", err)
+	}
+
+	var framed bytes.Buffer
+	if err := binary.Write(&framed, binary.BigEndian, uint32(body.Len())); err != nil {
+		return nil, fmt.Errorf("framing WAL entry: %w", err)
+	}
+	framed.Write(body.Bytes())
+
+	return framed.Bytes(), nil
+}
+
+// decodeWALEntry reads one length-prefixed gob entry from r, returning the
+// entry and the total number of bytes consumed (prefix included).
+func decodeWALEntry(r *bufio.Reader) (lokiLogEntry, int64, error) {
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return lokiLogEntry{}, 0, err
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return lokiLogEntry{}, 0, err
+	}
+
+	var decoded walEntry
+	if err := gob.NewDecoder(bytes.NewReader(body)).Decode(&decoded); err != nil {
+		return lokiLogEntry{}, 0, fmt.Errorf("decoding WAL entry: %w", err)
+	}
+
+	return lokiLogEntry{
+		Timestamp: decoded.Timestamp,
+		Line:      decoded.Line,
+		Level:     decoded.Level,
+		Labels:    decoded.Labels,
+		Metadata:  decoded.Metadata,
+	}, int64(4 + length), nil
+}
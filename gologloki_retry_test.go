@@ -0,0 +1,119 @@
+package gologloki
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestRetryDelayBounds checks that retryDelay stays within the documented
+// full-jitter envelope and is capped at RetryMaxBackoff.
+func TestRetryDelayBounds(t *testing.T) {
+	cfg := defaultLokiConfig("http://example.invalid")
+	cfg.RetryMinBackoff = 100 * time.Millisecond
+	cfg.RetryMaxBackoff = time.Second
+	adapter := &lokiAdapter{cfg: cfg}
+
+	cases := []struct {
+		attempt  int
+		wantBase time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second}, // exceeds RetryMaxBackoff, so capped
+	}
+
+	for _, c := range cases {
+		delay := adapter.retryDelay(c.attempt)
+		min := time.Duration(float64(c.wantBase) * 0.8)
+		max := time.Duration(float64(c.wantBase) * 1.2)
+		if delay < min || delay > max {
+			t.Errorf("retryDelay(%d) = %v, want within [%v, %v]", c.attempt, delay, min, max)
+		}
+	}
+}
+
+// TestSendWithRetryGivesUpAfterMaxAttempts checks that a batch that never
+// succeeds is sent exactly RetryMaxAttempts times in total and then dropped
+// via OnDropped, rather than retried forever.
+func TestSendWithRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	var requests int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	var dropped []DroppedEntry
+	cfg := defaultLokiConfig(srv.URL)
+	cfg.RetryMaxAttempts = 3
+	cfg.RetryMinBackoff = time.Millisecond
+	cfg.RetryMaxBackoff = 5 * time.Millisecond
+	cfg.OnDropped = func(entries []DroppedEntry, err error) {
+		dropped = entries
+	}
+
+	adapter := newLokiAdapter(cfg)
+	defer adapter.Close(context.Background())
+
+	entries := []lokiLogEntry{{Line: "boom"}}
+	ok, err := adapter.sendWithRetry(context.Background(), entries, lokiPayload{})
+	if ok || err == nil {
+		t.Fatalf("sendWithRetry() = (%v, %v), want (false, non-nil)", ok, err)
+	}
+
+	if got := atomic.LoadInt32(&requests); got != int32(cfg.RetryMaxAttempts) {
+		t.Errorf("server got %d requests, want %d (RetryMaxAttempts)", got, cfg.RetryMaxAttempts)
+	}
+	if len(dropped) != 1 || dropped[0].Line != "boom" {
+		t.Errorf("OnDropped entries = %+v, want the one dropped entry", dropped)
+	}
+}
+
+// TestSendWithRetryHonorsRetryAfter checks that a 429 response's
+// Retry-After header is honored before the next attempt, and that it
+// replaces the computed exponential backoff for that attempt rather than
+// being waited out in addition to it: RetryMinBackoff is set well above
+// Retry-After, so stacking the two would make this test fail.
+func TestSendWithRetryHonorsRetryAfter(t *testing.T) {
+	var requests int32
+	const retryAfterSeconds = 1
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	cfg := defaultLokiConfig(srv.URL)
+	cfg.RetryMaxAttempts = 2
+	cfg.RetryMinBackoff = 5 * time.Second
+	cfg.RetryMaxBackoff = 5 * time.Second
+
+	adapter := newLokiAdapter(cfg)
+	defer adapter.Close(context.Background())
+
+	start := time.Now()
+	ok, err := adapter.sendWithRetry(context.Background(), []lokiLogEntry{{Line: "hi"}}, lokiPayload{})
+	elapsed := time.Since(start)
+
+	if !ok || err != nil {
+		t.Fatalf("sendWithRetry() = (%v, %v), want (true, nil)", ok, err)
+	}
+	if elapsed < retryAfterSeconds*time.Second {
+		t.Errorf("sendWithRetry() returned after %v, want it to have waited out the %ds Retry-After", elapsed, retryAfterSeconds)
+	}
+	if elapsed >= cfg.RetryMinBackoff {
+		t.Errorf("sendWithRetry() returned after %v, want Retry-After (%ds) to have replaced the %v computed backoff, not stacked with it", elapsed, retryAfterSeconds, cfg.RetryMinBackoff)
+	}
+}
@@ -1,12 +1,32 @@
 package gologloki
 
 import (
+	"context"
+	"encoding/json"
 	"github.com/memUsins/golog"
+	"strconv"
 	"time"
 )
 
 type LokiAdapter interface {
 	golog.Adapter
+
+	// Close stops accepting new logs, drains and flushes whatever is
+	// buffered, and waits for the background goroutine to exit. The drain
+	// is bounded by ctx; if ctx is cancelled first, Close returns ctx.Err()
+	// and buffered logs may be lost.
+	Close(ctx context.Context) error
+
+	// Flush sends whatever is currently buffered without tearing the
+	// adapter down, e.g. as a synchronous checkpoint after panic recovery
+	// or at the end of a batch job. The send is bounded by ctx.
+	Flush(ctx context.Context) error
+
+	// WALDroppedBytes returns the cumulative bytes discarded by the
+	// write-ahead log's MaxBytes eviction (0 if Persistence is disabled),
+	// so operators can alert on silent WAL data loss instead of it going
+	// unreported.
+	WALDroppedBytes() int64
 }
 
 // lokiLogEntry loki log struct
@@ -15,12 +35,63 @@ type lokiLogEntry struct {
 	Line      string            `json:"line"`
 	Level     string            `json:"level"`
 	Labels    map[string]string `json:"labels"`
+
+	// Metadata carries fields named in LokiConfig.MetadataLabels: it rides
+	// along with the entry as Loki's per-entry structured metadata instead
+	// of becoming an indexed stream label.
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// walSegment and walOffset record where this entry lives in the
+	// write-ahead log when Persistence is enabled, so a successful flush
+	// can acknowledge exactly up to here. Zero when persistence is off.
+	walSegment uint64
+	walOffset  int64
+}
+
+// DroppedEntry is the exported, wire-independent view of a log entry passed
+// to LokiConfig.OnDropped; it mirrors lokiLogEntry minus the internal WAL
+// bookkeeping fields, which a caller outside this package has no use for.
+type DroppedEntry struct {
+	Timestamp time.Time
+	Line      string
+	Level     string
+	Labels    map[string]string
+	Metadata  map[string]string
+}
+
+// toDroppedEntry converts entry to the exported form handed to OnDropped.
+func (entry lokiLogEntry) toDroppedEntry() DroppedEntry {
+	return DroppedEntry{
+		Timestamp: entry.Timestamp,
+		Line:      entry.Line,
+		Level:     entry.Level,
+		Labels:    entry.Labels,
+		Metadata:  entry.Metadata,
+	}
 }
 
 // lokiStream loki stream struct
 type lokiStream struct {
 	Stream map[string]string `json:"stream"`
-	Values [][2]string       `json:"values"`
+	Values []lokiValue       `json:"values"`
+}
+
+// lokiValue is one log line within a stream: a timestamp, the line itself,
+// and optional structured metadata. It marshals the way Loki's push API
+// expects: a 2-element [timestamp, line] array, or a 3-element [timestamp,
+// line, structuredMetadata] array once Metadata is non-empty.
+type lokiValue struct {
+	Timestamp time.Time
+	Line      string
+	Metadata  map[string]string
+}
+
+func (v lokiValue) MarshalJSON() ([]byte, error) {
+	ts := strconv.FormatInt(v.Timestamp.UnixNano(), 10)
+	if len(v.Metadata) == 0 {
+		return json.Marshal([2]string{ts, v.Line})
+	}
+	return json.Marshal([3]interface{}{ts, v.Line, v.Metadata})
 }
 
 // lokiPayload loki payload struct